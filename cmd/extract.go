@@ -0,0 +1,157 @@
+/*
+Copyright © 2024 Terry Wallace terence.wallace@gmail.com
+*/
+package cmd
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+
+	gcrv1 "github.com/google/go-containerregistry/pkg/v1"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	extractOutput string
+
+	extractCmd = &cobra.Command{
+		Args:  cobra.NoArgs,
+		Use:   "extract",
+		Short: "Unpacks every layer of an image into a local directory",
+		Long: `Unpacks every layer of an image into a local directory, in order.
+
+Each layer's media type is inspected to pick a decompressor (tar+gzip,
+tar+zstd, or an uncompressed tar); entries whose path would escape the
+output directory are rejected.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			extractImage()
+		},
+	}
+)
+
+func init() {
+	extractCmd.Flags().StringVarP(&extractOutput, "output", "d", "", "Directory to extract layer contents into")
+	extractCmd.MarkFlagRequired("output")
+
+	ociCmd.AddCommand(extractCmd)
+}
+
+func extractImage() {
+	img, _, err := newClient().Image(context.Background(), ociImage)
+	if err != nil {
+		logger.Fatal(fmt.Sprintf("Error getting image: %s", err))
+	}
+
+	layers, err := img.Layers()
+	if err != nil {
+		logger.Fatal(fmt.Sprintf("Error getting layers: %s", err))
+	}
+
+	if err := os.MkdirAll(extractOutput, 0755); err != nil {
+		logger.Fatal(fmt.Sprintf("Error creating output dir: %s", err))
+	}
+
+	for i, layer := range layers {
+		if err := extractLayer(layer, extractOutput); err != nil {
+			logger.Fatal(fmt.Sprintf("Error extracting layer %d: %s", i, err))
+		}
+	}
+
+	logger.Info(fmt.Sprintf("Extracted %d layer(s) to %s", len(layers), extractOutput))
+}
+
+func extractLayer(layer gcrv1.Layer, dest string) error {
+	mediaType, err := layer.MediaType()
+	if err != nil {
+		return fmt.Errorf("getting media type: %w", err)
+	}
+
+	blob, err := layer.Compressed()
+	if err != nil {
+		return fmt.Errorf("getting layer reader: %w", err)
+	}
+	defer blob.Close()
+
+	logger.Debug(fmt.Sprintf("Extracting layer with media type %s", mediaType))
+
+	var r io.Reader
+	switch {
+	case strings.Contains(string(mediaType), "zstd"):
+		zr, err := zstd.NewReader(blob)
+		if err != nil {
+			return fmt.Errorf("opening zstd reader: %w", err)
+		}
+		defer zr.Close()
+		r = zr
+	case strings.Contains(string(mediaType), "gzip"):
+		gr, err := gzip.NewReader(blob)
+		if err != nil {
+			return fmt.Errorf("opening gzip reader: %w", err)
+		}
+		defer gr.Close()
+		r = gr
+	default:
+		r = blob
+	}
+
+	return untar(r, dest)
+}
+
+// untar extracts a tar stream into dest, rejecting any entry whose cleaned
+// path would resolve outside dest.
+func untar(r io.Reader, dest string) error {
+	root := filepath.Clean(dest)
+	tr := tar.NewReader(r)
+
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("reading tar entry: %w", err)
+		}
+
+		target := filepath.Join(root, hdr.Name)
+		rel, err := filepath.Rel(root, target)
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(os.PathSeparator)) {
+			return fmt.Errorf("tar entry %q escapes output directory", hdr.Name)
+		}
+
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, 0755); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+				return err
+			}
+
+			f, err := os.OpenFile(target, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, os.FileMode(hdr.Mode))
+			if err != nil {
+				return err
+			}
+
+			if _, err := io.Copy(f, tr); err != nil {
+				f.Close()
+				return err
+			}
+
+			if err := f.Close(); err != nil {
+				return err
+			}
+		default:
+			logger.Debug(fmt.Sprintf("Skipping tar entry %s with type %d", hdr.Name, hdr.Typeflag))
+		}
+	}
+}
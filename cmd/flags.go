@@ -8,6 +8,16 @@ var (
 	// ociRepo  string
 	verbose bool
 
+	cacheDir string
+	noCache  bool
+	refresh  bool
+
+	verifySig      bool
+	cosignKey      string
+	cosignIdentity string
+	cosignIssuer   string
+	rekorKey       string
+
 	ociCmd = &cobra.Command{
 		Args:  cobra.OnlyValidArgs,
 		Use:   "oci-repo",
@@ -32,4 +42,13 @@ func init() {
 	// ociCmd.MarkPersistentFlagRequired("ociRepo")
 	ociCmd.PersistentFlags().BoolVarP(&verbose, "verbose", "v", false, "Verbose output")
 
+	ociCmd.PersistentFlags().StringVar(&cacheDir, "cache-dir", "", "Directory to cache pulled layer blobs in (default $XDG_CACHE_HOME/go-acr)")
+	ociCmd.PersistentFlags().BoolVar(&noCache, "no-cache", false, "Disable the layer cache")
+	ociCmd.PersistentFlags().BoolVar(&refresh, "refresh", false, "Ignore any cached manifest and re-pull from the registry")
+
+	ociCmd.Flags().BoolVar(&verifySig, "verify", false, "Verify the image's cosign signature before writing any layer to disk")
+	ociCmd.PersistentFlags().StringVar(&cosignKey, "cosign-key", "", "Path to a PEM-encoded cosign public key for --verify, or a private key for push --sign")
+	ociCmd.PersistentFlags().StringVar(&cosignIdentity, "cosign-identity", "", "Expected keyless signing identity (SAN) for --verify")
+	ociCmd.PersistentFlags().StringVar(&cosignIssuer, "cosign-issuer", "", "Expected keyless signing OIDC issuer for --verify")
+	ociCmd.PersistentFlags().StringVar(&rekorKey, "rekor-key", "", "Path to a PEM-encoded Rekor public key, overriding the pinned production key")
 }
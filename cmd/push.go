@@ -0,0 +1,237 @@
+/*
+Copyright © 2024 Terry Wallace terence.wallace@gmail.com
+*/
+package cmd
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	gcrv1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/tarball"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	pushOsArch  []string
+	pushVersion string
+	pushLatest  bool
+	pushCreds   string
+	pushSign    bool
+
+	pushCmd = &cobra.Command{
+		Args:  cobra.MinimumNArgs(1),
+		Use:   "push <artifact-dir> [artifact-dir...]",
+		Short: "Publishes one or more local artifact directories to an OCI registry",
+		Long: `Publishes one or more local artifact directories to an OCI registry.
+
+Each directory is tagged with a matching --os-arch value (e.g. linux/amd64,
+darwin/arm64), given in the same order as the directories. When more than one
+directory is provided, the result is pushed as an
+application/vnd.oci.image.index.v1+json manifest with one child image per
+platform; a single directory is pushed as a plain image manifest.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			pushImages(args)
+		},
+	}
+)
+
+func init() {
+	pushCmd.Flags().StringArrayVar(&pushOsArch, "os-arch", nil, "os/arch pair for the artifact directory at the matching position (e.g. linux/amd64); repeatable")
+	pushCmd.Flags().StringVar(&pushVersion, "version", "", "Tag to push the image or index as")
+	pushCmd.Flags().BoolVar(&pushLatest, "latest", false, "Additionally tag the image or index as latest")
+	pushCmd.Flags().StringVar(&pushCreds, "creds", "", "Registry credentials in user:pass form; uses the default keychain when empty")
+	pushCmd.Flags().BoolVar(&pushSign, "sign", false, "Sign each pushed tag with cosign, using --cosign-key as the private key")
+
+	ociCmd.AddCommand(pushCmd)
+}
+
+func pushAuthOption() remote.Option {
+	if pushCreds == "" {
+		return remote.WithAuthFromKeychain(authn.DefaultKeychain)
+	}
+
+	user, pass, ok := strings.Cut(pushCreds, ":")
+	if !ok {
+		logger.Fatal(fmt.Sprintf("Creds must be in the form user:pass, got %q", pushCreds))
+	}
+
+	return remote.WithAuth(&authn.Basic{Username: user, Password: pass})
+}
+
+func pushImages(artifactDirs []string) {
+	if len(pushOsArch) != len(artifactDirs) {
+		logger.Fatal(fmt.Sprintf("Expected %d --os-arch flags to match %d artifact directories, got %d", len(artifactDirs), len(artifactDirs), len(pushOsArch)))
+	}
+
+	repo, err := name.NewRepository(strings.TrimPrefix(ociImage, "oci://"))
+	if err != nil {
+		logger.Fatal(fmt.Sprintf("Error parsing repository: %s", err))
+	}
+
+	auth := pushAuthOption()
+
+	images := make([]gcrv1.Image, 0, len(artifactDirs))
+	platforms := make([]*gcrv1.Platform, 0, len(artifactDirs))
+
+	for i, dir := range artifactDirs {
+		osName, arch, ok := strings.Cut(pushOsArch[i], "/")
+		if !ok {
+			logger.Fatal(fmt.Sprintf("--os-arch must be in the form os/arch, got %q", pushOsArch[i]))
+		}
+
+		img, err := imageFromDir(dir)
+		if err != nil {
+			logger.Fatal(fmt.Sprintf("Error building image from %s: %s", dir, err))
+		}
+
+		images = append(images, img)
+		platforms = append(platforms, &gcrv1.Platform{OS: osName, Architecture: arch})
+	}
+
+	tags := pushTags()
+
+	if len(images) == 1 {
+		for _, tag := range tags {
+			ref := repo.Tag(tag)
+			logger.Info(fmt.Sprintf("Pushing image %s", ref))
+			if err := remote.Write(ref, images[0], auth); err != nil {
+				logger.Fatal(fmt.Sprintf("Error pushing image: %s", err))
+			}
+			pushSignTag(ref)
+		}
+		return
+	}
+
+	var idx gcrv1.ImageIndex = empty.Index
+	for i, img := range images {
+		idx = mutate.AppendManifests(idx, mutate.IndexAddendum{
+			Add: img,
+			Descriptor: gcrv1.Descriptor{
+				Platform: platforms[i],
+			},
+		})
+	}
+
+	for _, tag := range tags {
+		ref := repo.Tag(tag)
+		logger.Info(fmt.Sprintf("Pushing image index %s", ref))
+		if err := remote.WriteIndex(ref, idx, auth); err != nil {
+			logger.Fatal(fmt.Sprintf("Error pushing image index: %s", err))
+		}
+		pushSignTag(ref)
+	}
+}
+
+// pushSignTag signs ref with cosign when --sign was requested, using
+// --cosign-key as the private key.
+func pushSignTag(ref name.Tag) {
+	if !pushSign {
+		return
+	}
+
+	if cosignKey == "" {
+		logger.Fatal("--sign requires --cosign-key to point at a private key")
+	}
+
+	if _, err := newClient().Sign(context.Background(), ref.Name(), cosignKey); err != nil {
+		logger.Fatal(fmt.Sprintf("Error signing %s: %s", ref, err))
+	}
+}
+
+func pushTags() []string {
+	version := pushVersion
+	if version == "" {
+		version = "latest"
+	}
+
+	tags := []string{version}
+	if pushLatest && version != "latest" {
+		tags = append(tags, "latest")
+	}
+
+	return tags
+}
+
+// imageFromDir packages the contents of dir into a single-layer image whose
+// layer is a tarball of the directory tree, gzip-compressed by
+// tarball.LayerFromOpener and published as a Docker gzip layer.
+func imageFromDir(dir string) (gcrv1.Image, error) {
+	layer, err := tarball.LayerFromOpener(func() (io.ReadCloser, error) {
+		buf, err := tarDir(dir)
+		if err != nil {
+			return nil, err
+		}
+		return io.NopCloser(buf), nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("building layer: %w", err)
+	}
+
+	return mutate.AppendLayers(empty.Image, layer)
+}
+
+func tarDir(dir string) (*bytes.Buffer, error) {
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		hdr, err := tar.FileInfoHeader(info, "")
+		if err != nil {
+			return err
+		}
+		hdr.Name = rel
+
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		_, err = io.Copy(tw, f)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tw.Close(); err != nil {
+		return nil, err
+	}
+
+	return &buf, nil
+}
@@ -4,22 +4,19 @@ Copyright © 2024 Terry Wallace terence.wallace@gmail.com
 package cmd
 
 import (
+	"bytes"
+	"context"
 	"fmt"
-	"io"
 	"os"
-	"slices"
-	"strings"
 
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 
-	"github.com/google/go-containerregistry/pkg/authn"
-	"github.com/google/go-containerregistry/pkg/name"
-	gcrv1 "github.com/google/go-containerregistry/pkg/v1"
-	"github.com/google/go-containerregistry/pkg/v1/remote"
-
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
+
+	"github.com/twallac10/acr-test/internal/cache"
+	"github.com/twallac10/acr-test/pkg/oci"
 )
 
 var cfgFile string
@@ -71,123 +68,144 @@ func initConfig() {
 	}
 }
 
-func getImage() {
-	logger.Debug(fmt.Sprintf("Checking image %s", ociImage))
-	//Take the image name and pull it from the registry
+// newClient returns the pkg/oci.Client used by every subcommand, configured
+// with the shared logger.
+func newClient() *oci.Client {
+	return oci.NewClient(logger)
+}
 
-	if !strings.HasPrefix(ociImage, "oci://") {
-		logger.Fatal("Image must be in the format oci://<domain>/<org>/<repo>")
+// openCache returns the layer cache to use for this invocation, or nil if
+// caching has been disabled with --no-cache.
+func openCache() *cache.Cache {
+	if noCache {
+		return nil
 	}
 
-	url := strings.TrimPrefix(ociImage, "oci://")
+	dir := cacheDir
+	if dir == "" {
+		var err error
+		dir, err = cache.DefaultDir()
+		if err != nil {
+			logger.Fatal(fmt.Sprintf("Error resolving cache dir: %s", err))
+		}
+	}
+
+	c, err := cache.New(dir)
+	if err != nil {
+		logger.Fatal(fmt.Sprintf("Error opening cache: %s", err))
+	}
 
-	tag := strings.Split(url, ":")[1]
+	return c
+}
 
-	logger.Debug(fmt.Sprintf("Tag: %s", tag))
-	logger.Debug(fmt.Sprintf("URL: %s", url))
+func getImage() {
+	logger.Debug(fmt.Sprintf("Checking image %s", ociImage))
 
-	options := []name.Option{}
+	ctx := context.Background()
+	client := newClient()
 
-	r, err := name.NewRepository(strings.Split(url, ":")[0], options...)
+	desc, err := client.Resolve(ctx, ociImage)
 	if err != nil {
-		logger.Fatal(fmt.Sprintf("Error parsing repository: %s", err))
+		logger.Fatal(fmt.Sprintf("Error resolving image: %s", err))
 	}
 
-	logger.Debug(fmt.Sprintf("Repository: %s", r))
+	logger.Info(fmt.Sprintf("Pulling image %s@%s", desc.Ref, desc.Digest))
 
-	tags, err := remote.List(r, remote.WithAuthFromKeychain(authn.DefaultKeychain))
-	if err != nil {
-		logger.Fatal(fmt.Sprintf("Error listing tags: %s", err))
+	if verifySig {
+		subject, err := client.VerifySignature(ctx, ociImage, oci.VerifyOptions{
+			CosignKeyPath:      cosignKey,
+			Identity:           cosignIdentity,
+			Issuer:             cosignIssuer,
+			RekorPublicKeyPath: rekorKey,
+		})
+		if err != nil {
+			logger.Fatal(fmt.Sprintf("Error verifying signature: %s", err))
+		}
+		logger.Info(fmt.Sprintf("Verified signature of %s by %s", subject.Digest, subject.Signer))
 	}
 
-	logger.Debug(fmt.Sprintf("Tags: %s", tags))
+	blobCache := openCache()
 
-	if tag == "" {
-		tag = "latest"
+	if blobCache != nil && !refresh {
+		if m, ok := blobCache.Fresh(desc.Ref.Name(), desc.Digest.String()); ok {
+			logger.Info("Manifest unchanged and all layers cached, skipping registry fetch")
+			writeLayerFromCache(blobCache, m.Layers[0])
+			return
+		}
 	}
 
-	if !slices.Contains(tags, tag) {
-		logger.Debug(fmt.Sprintf("Tag %s not found in repository", tag))
+	dir, err := os.MkdirTemp("/tmp", "layer")
+	if err != nil {
+		logger.Fatal(fmt.Sprintf("Error creating temp dir: %s", err))
 	}
 
-	ref, err := name.ParseReference(url)
-	if err != nil {
-		logger.Fatal(fmt.Sprintf("Error parsing image reference: %s", err))
-	}
-
-	var revision string
-	switch ref.(type) {
-	case name.Tag:
-		var digest gcrv1.Hash
-		logger.Debug("Tagged image")
-
-		desc, err := remote.Head(ref, remote.WithAuthFromKeychain(authn.DefaultKeychain))
-		if err == nil {
-			digest = desc.Digest
-			logger.Debug(fmt.Sprintf("Digest from Head: %s", digest.String()))
-		} else {
-			gdesc, err := remote.Get(ref, remote.WithAuthFromKeychain(authn.DefaultKeychain))
-			if err != nil {
-				logger.Error(fmt.Sprintf("Error getting image: %s", err))
-			}
-			digest = gdesc.Descriptor.Digest
-		}
+	// defer os.RemoveAll(dir)
 
-		revision = fmt.Sprintf("%s@%s", tag, digest.String())
+	store, err := oci.NewFSStore(dir)
+	if err != nil {
+		logger.Fatal(fmt.Sprintf("Error creating layer store: %s", err))
+	}
 
-		logger.Info(fmt.Sprintf("Pulling image %s", revision))
+	if _, err := client.Pull(ctx, ociImage, store); err != nil {
+		logger.Fatal(fmt.Sprintf("Error pulling image: %s", err))
 	}
 
-	img, err := remote.Image(ref, remote.WithAuthFromKeychain(authn.DefaultKeychain))
+	names, err := store.List(ctx)
 	if err != nil {
-		logger.Fatal(fmt.Sprintf("Error getting image: %s", err))
+		logger.Fatal(fmt.Sprintf("Error listing pulled layers: %s", err))
 	}
-	layers, err := img.Layers()
-	if err != nil {
-		logger.Fatal(fmt.Sprintf("Error getting layers: %s", err))
+	if len(names) == 0 {
+		logger.Fatal("Error: image has no layers")
 	}
 
-	var layer gcrv1.Layer
+	outPath := fmt.Sprintf("%s/%s", dir, "layer.tar.gz")
+
+	if names[0] != "layer.tar.gz" {
+		if err := os.Rename(fmt.Sprintf("%s/%s", dir, names[0]), outPath); err != nil {
+			logger.Fatal(fmt.Sprintf("Error renaming layer: %s", err))
+		}
+	}
 
-	for _, l := range layers {
-		mediaType, _ := l.MediaType()
-		ld, err := l.Digest()
-		logger.Debug(fmt.Sprintf("Layer Digest: %s", ld.String()))
-		logger.Debug(fmt.Sprintf("MediaType: %s", mediaType))
-		size, _ := l.Size()
-		logger.Debug(fmt.Sprintf("Size: %d", size))
+	if blobCache != nil {
+		data, err := os.ReadFile(outPath)
 		if err != nil {
-			logger.Error(fmt.Sprintf("Error getting media type: %s", err))
-			continue
+			logger.Error(fmt.Sprintf("Error reading pulled layer for cache: %s", err))
+		} else if err := blobCache.WriteBlob(names[0], bytes.NewReader(data)); err != nil {
+			logger.Error(fmt.Sprintf("Error writing layer to cache: %s", err))
+		} else if err := blobCache.SetManifest(desc.Ref.Name(), cache.Manifest{
+			Digest: desc.Digest.String(),
+			Layers: []string{names[0]},
+		}); err != nil {
+			logger.Error(fmt.Sprintf("Error recording manifest in cache: %s", err))
 		}
-		logger.Debug(fmt.Sprintf("Layer: %s", mediaType))
 	}
 
-	layer = layers[0]
+	logger.Info(fmt.Sprintf("Layer written to %s", outPath))
+}
 
-	dir, err := os.MkdirTemp("/tmp", "layer")
+// writeLayerFromCache re-materializes the cached layer blob identified by
+// digest into a fresh temp dir, without contacting the registry.
+func writeLayerFromCache(c *cache.Cache, digest string) {
+	src, err := c.BlobPath(digest)
 	if err != nil {
-		logger.Fatal(fmt.Sprintf("Error creating temp dir: %s", err))
+		logger.Fatal(fmt.Sprintf("Error resolving cached blob path: %s", err))
 	}
 
-	// defer os.RemoveAll(dir)
-
-	blob, err := layer.Compressed()
+	dir, err := os.MkdirTemp("/tmp", "layer")
 	if err != nil {
-		logger.Fatal(fmt.Sprintf("Error getting compressed layer: %s", err))
+		logger.Fatal(fmt.Sprintf("Error creating temp dir: %s", err))
 	}
 
-	defer blob.Close()
-
-	data, err := io.ReadAll(blob)
+	data, err := os.ReadFile(src)
 	if err != nil {
-		logger.Fatal(fmt.Sprintf("Error reading blob: %s", err))
+		logger.Fatal(fmt.Sprintf("Error reading cached blob: %s", err))
 	}
 
-	err = os.WriteFile(fmt.Sprintf("%s/%s", dir, "layer.tar.gz"), data, 0644)
-	if err != nil {
+	outPath := fmt.Sprintf("%s/%s", dir, "layer.tar.gz")
+
+	if err := os.WriteFile(outPath, data, 0644); err != nil {
 		logger.Fatal(fmt.Sprintf("Error writing file: %s", err))
 	}
 
-	logger.Info(fmt.Sprintf("Layer written to %s/layer.tar.gz", dir))
+	logger.Info(fmt.Sprintf("Layer written to %s", outPath))
 }
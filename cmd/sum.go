@@ -0,0 +1,103 @@
+/*
+Copyright © 2024 Terry Wallace terence.wallace@gmail.com
+*/
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/name"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	sumOutput     string
+	sumCodegen    bool
+	sumPackage    string
+	sumIdentifier string
+
+	sumCmd = &cobra.Command{
+		Args:  cobra.NoArgs,
+		Use:   "sum",
+		Short: "Resolves an image to its manifest digest and prints a sha256sum-style pin",
+		Long: `Resolves an image reference to its manifest digest and writes a
+sha256sum-formatted line of the form "<hex>  <repo>:<tag>@sha256:<hex>" to
+stdout or --output.
+
+With --codegen, also emits a companion Go source file declaring the digest
+and repo:tag as constants, so downstream builds can consume the pin without
+re-hitting the registry.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			sumImage()
+		},
+	}
+)
+
+func init() {
+	sumCmd.Flags().StringVar(&sumOutput, "output", "", "File to write the sum line to (default stdout)")
+	sumCmd.Flags().BoolVar(&sumCodegen, "codegen", false, "Additionally emit a Go source file declaring the digest as constants")
+	sumCmd.Flags().StringVar(&sumPackage, "package", "", "Package name for --codegen output (required with --codegen)")
+	sumCmd.Flags().StringVar(&sumIdentifier, "identifier", "", "Identifier prefix for --codegen constants, e.g. Bar produces BarDigest and BarRepoTag (required with --codegen)")
+
+	ociCmd.AddCommand(sumCmd)
+}
+
+func sumImage() {
+	desc, err := newClient().Resolve(context.Background(), ociImage)
+	if err != nil {
+		logger.Fatal(fmt.Sprintf("Error resolving image: %s", err))
+	}
+
+	repoTag := refRepoTag(desc.Ref)
+	line := fmt.Sprintf("%s  %s@%s\n", desc.Digest.Hex, repoTag, desc.Digest.String())
+
+	if sumOutput == "" {
+		fmt.Print(line)
+	} else if err := os.WriteFile(sumOutput, []byte(line), 0644); err != nil {
+		logger.Fatal(fmt.Sprintf("Error writing sum file: %s", err))
+	}
+
+	if !sumCodegen {
+		return
+	}
+
+	if sumPackage == "" || sumIdentifier == "" {
+		logger.Fatal("--codegen requires --package and --identifier")
+	}
+
+	if err := writeDigestSource(sumPackage, sumIdentifier, desc.Digest.String(), repoTag); err != nil {
+		logger.Fatal(fmt.Sprintf("Error writing codegen source: %s", err))
+	}
+}
+
+// refRepoTag renders ref as "<repo>:<tag>" for a tagged reference, or
+// "<repo>" for a digest-pinned one, to pair with the "@sha256:<hex>" suffix
+// in the sum line.
+func refRepoTag(ref name.Reference) string {
+	switch r := ref.(type) {
+	case name.Tag:
+		return fmt.Sprintf("%s:%s", r.Repository.Name(), r.TagStr())
+	case name.Digest:
+		return r.Repository.Name()
+	default:
+		return ref.Name()
+	}
+}
+
+func writeDigestSource(pkg, identifier, digest, repoTag string) error {
+	src := fmt.Sprintf(`// Code generated by "go-acr sum --codegen". DO NOT EDIT.
+
+package %s
+
+const %sDigest = %q
+const %sRepoTag = %q
+`, pkg, identifier, digest, identifier, repoTag)
+
+	path := fmt.Sprintf("%s_digest.go", strings.ToLower(identifier))
+
+	return os.WriteFile(path, []byte(src), 0644)
+}
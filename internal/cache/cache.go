@@ -0,0 +1,187 @@
+// Package cache provides a persistent, digest-verified blob cache for pulled
+// OCI layers, plus a small ETag-style record of the last manifest resolved
+// for a reference so repeat pulls of an unchanged manifest can skip fetching
+// the image and its layers.
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Manifest is the cached record of the last manifest digest resolved for a
+// reference, along with the layer digests it referenced, so a later pull can
+// tell whether every blob it needs is already on disk without re-fetching
+// the manifest.
+type Manifest struct {
+	Digest string   `json:"digest"`
+	Layers []string `json:"layers"`
+}
+
+// Cache is a directory-backed store of content-addressed blobs and
+// per-reference manifest records.
+type Cache struct {
+	dir string
+}
+
+// New returns a Cache rooted at dir, creating it if necessary.
+func New(dir string) (*Cache, error) {
+	if err := os.MkdirAll(filepath.Join(dir, "blobs", "sha256"), 0755); err != nil {
+		return nil, fmt.Errorf("creating cache dir: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Join(dir, "manifests"), 0755); err != nil {
+		return nil, fmt.Errorf("creating cache dir: %w", err)
+	}
+	return &Cache{dir: dir}, nil
+}
+
+// DefaultDir returns go-acr under os.UserCacheDir: $XDG_CACHE_HOME/go-acr on
+// Unix systems other than macOS, falling back to $HOME/.cache/go-acr when
+// XDG_CACHE_HOME is unset. On macOS and Windows XDG_CACHE_HOME is not
+// consulted and the OS default user cache directory is used.
+func DefaultDir() (string, error) {
+	base, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("resolving cache dir: %w", err)
+	}
+	return filepath.Join(base, "go-acr"), nil
+}
+
+// BlobPath returns the on-disk path for a "sha256:<hex>" digest, regardless
+// of whether it has been written yet.
+func (c *Cache) BlobPath(digest string) (string, error) {
+	h, err := hexDigest(digest)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(c.dir, "blobs", "sha256", h), nil
+}
+
+// HasBlob reports whether digest is already present on disk.
+func (c *Cache) HasBlob(digest string) bool {
+	path, err := c.BlobPath(digest)
+	if err != nil {
+		return false
+	}
+	_, err = os.Stat(path)
+	return err == nil
+}
+
+// WriteBlob streams r into the cache under digest, verifying the content
+// hashes to digest before it is renamed into place. It is written to a temp
+// file in the cache dir first so the rename is atomic and partial writes are
+// never observable under the final name.
+func (c *Cache) WriteBlob(digest string, r io.Reader) error {
+	path, err := c.BlobPath(digest)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), "blob-*.tmp")
+	if err != nil {
+		return fmt.Errorf("creating temp file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	h := sha256.New()
+	if _, err := io.Copy(tmp, io.TeeReader(r, h)); err != nil {
+		tmp.Close()
+		return fmt.Errorf("writing blob: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("closing temp file: %w", err)
+	}
+
+	got := "sha256:" + hex.EncodeToString(h.Sum(nil))
+	if got != digest {
+		return fmt.Errorf("digest mismatch: want %s, got %s", digest, got)
+	}
+
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("renaming blob into place: %w", err)
+	}
+
+	return nil
+}
+
+// Manifest returns the last manifest record for ref, if any.
+func (c *Cache) Manifest(ref string) (Manifest, bool) {
+	data, err := os.ReadFile(c.manifestPath(ref))
+	if err != nil {
+		return Manifest{}, false
+	}
+
+	var m Manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return Manifest{}, false
+	}
+
+	return m, true
+}
+
+// SetManifest atomically records m as the last manifest resolved for ref.
+func (c *Cache) SetManifest(ref string, m Manifest) error {
+	data, err := json.Marshal(m)
+	if err != nil {
+		return fmt.Errorf("encoding manifest record: %w", err)
+	}
+
+	path := c.manifestPath(ref)
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), "manifest-*.tmp")
+	if err != nil {
+		return fmt.Errorf("creating temp file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("writing manifest record: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("closing temp file: %w", err)
+	}
+
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("renaming manifest record into place: %w", err)
+	}
+
+	return nil
+}
+
+// Fresh reports whether digest matches the cached manifest digest for ref
+// and every layer it references is already present in the blob cache.
+func (c *Cache) Fresh(ref, digest string) (Manifest, bool) {
+	m, ok := c.Manifest(ref)
+	if !ok || m.Digest != digest {
+		return Manifest{}, false
+	}
+
+	for _, l := range m.Layers {
+		if !c.HasBlob(l) {
+			return Manifest{}, false
+		}
+	}
+
+	return m, true
+}
+
+func (c *Cache) manifestPath(ref string) string {
+	sum := sha256.Sum256([]byte(ref))
+	return filepath.Join(c.dir, "manifests", hex.EncodeToString(sum[:]))
+}
+
+func hexDigest(digest string) (string, error) {
+	_, h, ok := strings.Cut(digest, ":")
+	if !ok || h == "" {
+		return "", errors.New("digest must be in the form sha256:<hex>")
+	}
+	return h, nil
+}
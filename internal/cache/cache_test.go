@@ -0,0 +1,80 @@
+package cache
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestWriteBlobRejectsDigestMismatch(t *testing.T) {
+	c, err := New(t.TempDir())
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	const digest = "sha256:0000000000000000000000000000000000000000000000000000000000000000"
+	err = c.WriteBlob(digest, strings.NewReader("not the claimed content"))
+	if err == nil {
+		t.Fatal("WriteBlob succeeded for content that doesn't match the claimed digest")
+	}
+
+	if c.HasBlob(digest) {
+		t.Error("blob is present after a failed, digest-mismatched WriteBlob")
+	}
+}
+
+func TestWriteBlobAcceptsMatchingDigest(t *testing.T) {
+	c, err := New(t.TempDir())
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	// sha256("hello")
+	const digest = "sha256:2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824"
+	if err := c.WriteBlob(digest, strings.NewReader("hello")); err != nil {
+		t.Fatalf("WriteBlob: %v", err)
+	}
+
+	if !c.HasBlob(digest) {
+		t.Error("blob is not present after a successful WriteBlob")
+	}
+}
+
+func TestFreshHitAndMiss(t *testing.T) {
+	c, err := New(t.TempDir())
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	const ref = "registry.example.com/repo:tag"
+	const digest = "sha256:2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824"
+	const otherDigest = "sha256:1111111111111111111111111111111111111111111111111111111111111111"
+
+	if _, ok := c.Fresh(ref, digest); ok {
+		t.Error("Fresh reported a hit before any manifest was ever recorded")
+	}
+
+	m := Manifest{Digest: digest, Layers: []string{digest}}
+	if err := c.SetManifest(ref, m); err != nil {
+		t.Fatalf("SetManifest: %v", err)
+	}
+
+	if _, ok := c.Fresh(ref, digest); ok {
+		t.Error("Fresh reported a hit before the referenced blob was cached")
+	}
+
+	if err := c.WriteBlob(digest, strings.NewReader("hello")); err != nil {
+		t.Fatalf("WriteBlob: %v", err)
+	}
+
+	got, ok := c.Fresh(ref, digest)
+	if !ok {
+		t.Fatal("Fresh reported a miss once the manifest and all its blobs were cached")
+	}
+	if got.Digest != digest {
+		t.Errorf("Fresh manifest digest = %s, want %s", got.Digest, digest)
+	}
+
+	if _, ok := c.Fresh(ref, otherDigest); ok {
+		t.Error("Fresh reported a hit for a digest that doesn't match the cached manifest")
+	}
+}
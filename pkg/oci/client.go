@@ -0,0 +1,238 @@
+// Package oci is a small, embeddable client for pulling, pushing, and
+// copying OCI/Docker images, factored out of the go-acr CLI so it can be
+// used from other Go programs and unit-tested against a fake registry
+// instead of only through the command-line flags.
+package oci
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	gcrv1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/static"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+
+	"go.uber.org/zap"
+)
+
+// Client pulls, pushes, and copies OCI images. Keychain and Transport are
+// optional; the zero value authenticates with the default keychain and uses
+// the default HTTP transport.
+type Client struct {
+	Keychain  authn.Keychain
+	Transport http.RoundTripper
+	Logger    *zap.Logger
+}
+
+// NewClient returns a Client that authenticates with the default keychain
+// and logs through logger. logger may be nil, in which case logging is
+// discarded.
+func NewClient(logger *zap.Logger) *Client {
+	return &Client{Keychain: authn.DefaultKeychain, Logger: logger}
+}
+
+// Descriptor identifies a resolved image reference.
+type Descriptor struct {
+	Ref    name.Reference
+	Digest gcrv1.Hash
+}
+
+func (c *Client) keychain() authn.Keychain {
+	if c.Keychain != nil {
+		return c.Keychain
+	}
+	return authn.DefaultKeychain
+}
+
+func (c *Client) logger() *zap.Logger {
+	if c.Logger != nil {
+		return c.Logger
+	}
+	return zap.NewNop()
+}
+
+func (c *Client) options() []remote.Option {
+	opts := []remote.Option{remote.WithAuthFromKeychain(c.keychain())}
+	if c.Transport != nil {
+		opts = append(opts, remote.WithTransport(c.Transport))
+	}
+	return opts
+}
+
+func parseRef(ref string) (name.Reference, error) {
+	return name.ParseReference(strings.TrimPrefix(ref, "oci://"))
+}
+
+// Resolve parses ref (accepting an optional oci:// prefix) and resolves it
+// to a manifest digest, without fetching the image itself.
+func (c *Client) Resolve(ctx context.Context, ref string) (Descriptor, error) {
+	r, err := parseRef(ref)
+	if err != nil {
+		return Descriptor{}, fmt.Errorf("parsing reference: %w", err)
+	}
+
+	switch t := r.(type) {
+	case name.Tag:
+		desc, err := remote.Head(r, c.options()...)
+		if err != nil {
+			return Descriptor{}, fmt.Errorf("resolving digest: %w", err)
+		}
+		return Descriptor{Ref: r, Digest: desc.Digest}, nil
+	case name.Digest:
+		h, err := gcrv1.NewHash(t.DigestStr())
+		if err != nil {
+			return Descriptor{}, fmt.Errorf("parsing digest: %w", err)
+		}
+		return Descriptor{Ref: r, Digest: h}, nil
+	default:
+		return Descriptor{}, fmt.Errorf("unsupported reference type %T", r)
+	}
+}
+
+// Image resolves ref and fetches the corresponding v1.Image.
+func (c *Client) Image(ctx context.Context, ref string) (gcrv1.Image, Descriptor, error) {
+	desc, err := c.Resolve(ctx, ref)
+	if err != nil {
+		return nil, Descriptor{}, err
+	}
+
+	img, err := remote.Image(desc.Ref, c.options()...)
+	if err != nil {
+		return nil, Descriptor{}, fmt.Errorf("getting image: %w", err)
+	}
+
+	return img, desc, nil
+}
+
+// Pull fetches every layer of ref and writes it into dst, keyed by the
+// layer's digest.
+func (c *Client) Pull(ctx context.Context, ref string, dst Store) (Descriptor, error) {
+	img, desc, err := c.Image(ctx, ref)
+	if err != nil {
+		return Descriptor{}, err
+	}
+
+	layers, err := img.Layers()
+	if err != nil {
+		return Descriptor{}, fmt.Errorf("getting layers: %w", err)
+	}
+
+	order := make([]string, 0, len(layers))
+
+	for _, layer := range layers {
+		ld, err := layer.Digest()
+		if err != nil {
+			return Descriptor{}, fmt.Errorf("getting layer digest: %w", err)
+		}
+
+		mt, err := layer.MediaType()
+		if err != nil {
+			return Descriptor{}, fmt.Errorf("getting layer media type: %w", err)
+		}
+
+		blob, err := layer.Compressed()
+		if err != nil {
+			return Descriptor{}, fmt.Errorf("getting layer reader: %w", err)
+		}
+
+		err = dst.Put(ctx, ld.String(), blob)
+		blob.Close()
+		if err != nil {
+			return Descriptor{}, fmt.Errorf("writing layer %s: %w", ld, err)
+		}
+
+		if err := dst.SetMediaType(ctx, ld.String(), string(mt)); err != nil {
+			return Descriptor{}, fmt.Errorf("recording media type for layer %s: %w", ld, err)
+		}
+
+		order = append(order, ld.String())
+		c.logger().Debug(fmt.Sprintf("Pulled layer %s", ld))
+	}
+
+	if err := dst.SetOrder(ctx, order); err != nil {
+		return Descriptor{}, fmt.Errorf("recording layer order: %w", err)
+	}
+
+	return desc, nil
+}
+
+// Push reads every blob out of src and publishes them, in src's recorded
+// layer order (see Store.Order), as the layers of a single image manifest
+// at ref.
+func (c *Client) Push(ctx context.Context, ref string, src Store) (Descriptor, error) {
+	r, err := parseRef(ref)
+	if err != nil {
+		return Descriptor{}, fmt.Errorf("parsing reference: %w", err)
+	}
+
+	names, err := src.Order(ctx)
+	if err != nil {
+		return Descriptor{}, fmt.Errorf("listing source blobs: %w", err)
+	}
+
+	img := empty.Image
+
+	for _, n := range names {
+		rc, err := src.Get(ctx, n)
+		if err != nil {
+			return Descriptor{}, fmt.Errorf("reading blob %s: %w", n, err)
+		}
+
+		data, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			return Descriptor{}, fmt.Errorf("reading blob %s: %w", n, err)
+		}
+
+		mt, err := src.MediaType(ctx, n)
+		if err != nil {
+			return Descriptor{}, fmt.Errorf("getting media type for blob %s: %w", n, err)
+		}
+		if mt == "" {
+			mt = string(types.OCILayer)
+		}
+
+		img, err = mutate.AppendLayers(img, static.NewLayer(data, types.MediaType(mt)))
+		if err != nil {
+			return Descriptor{}, fmt.Errorf("appending layer %s: %w", n, err)
+		}
+	}
+
+	if err := remote.Write(r, img, c.options()...); err != nil {
+		return Descriptor{}, fmt.Errorf("pushing image: %w", err)
+	}
+
+	digest, err := img.Digest()
+	if err != nil {
+		return Descriptor{}, fmt.Errorf("getting pushed image digest: %w", err)
+	}
+
+	c.logger().Info(fmt.Sprintf("Pushed image %s@%s", r, digest))
+
+	return Descriptor{Ref: r, Digest: digest}, nil
+}
+
+// Copy pulls srcRef into an in-memory store and pushes it straight back out
+// to dstRef, without ever writing to disk.
+func (c *Client) Copy(ctx context.Context, srcRef, dstRef string) (Descriptor, error) {
+	mem := NewMemStore()
+
+	if _, err := c.Pull(ctx, srcRef, mem); err != nil {
+		return Descriptor{}, fmt.Errorf("pulling %s: %w", srcRef, err)
+	}
+
+	desc, err := c.Push(ctx, dstRef, mem)
+	if err != nil {
+		return Descriptor{}, fmt.Errorf("pushing %s: %w", dstRef, err)
+	}
+
+	return desc, nil
+}
@@ -0,0 +1,190 @@
+package oci
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/static"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+// newTestRepo starts an in-memory fake registry and returns the repository
+// portion of a reference rooted at it (no tag).
+func newTestRepo(t *testing.T) string {
+	t.Helper()
+
+	srv := httptest.NewServer(registry.New())
+	t.Cleanup(srv.Close)
+
+	return strings.TrimPrefix(srv.URL, "http://") + "/test/image"
+}
+
+func TestPushPullRoundTripPreservesLayerOrder(t *testing.T) {
+	ctx := context.Background()
+	c := NewClient(nil)
+	ref := newTestRepo(t) + ":v1"
+
+	layers := [][]byte{[]byte("base layer"), []byte("overlay layer")}
+
+	src := NewMemStore()
+	for i, data := range layers {
+		if err := src.Put(ctx, fmt.Sprintf("layer-%d", i), bytes.NewReader(data)); err != nil {
+			t.Fatalf("Put: %v", err)
+		}
+	}
+	if err := src.SetOrder(ctx, []string{"layer-0", "layer-1"}); err != nil {
+		t.Fatalf("SetOrder: %v", err)
+	}
+
+	if _, err := c.Push(ctx, ref, src); err != nil {
+		t.Fatalf("Push: %v", err)
+	}
+
+	dst := NewMemStore()
+	if _, err := c.Pull(ctx, ref, dst); err != nil {
+		t.Fatalf("Pull: %v", err)
+	}
+
+	order, err := dst.Order(ctx)
+	if err != nil {
+		t.Fatalf("Order: %v", err)
+	}
+	if len(order) != len(layers) {
+		t.Fatalf("got %d layers, want %d", len(order), len(layers))
+	}
+
+	for i, name := range order {
+		rc, err := dst.Get(ctx, name)
+		if err != nil {
+			t.Fatalf("Get(%s): %v", name, err)
+		}
+		got, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			t.Fatalf("reading %s: %v", name, err)
+		}
+		if !bytes.Equal(got, layers[i]) {
+			t.Errorf("layer %d = %q, want %q (layer order not preserved)", i, got, layers[i])
+		}
+	}
+}
+
+func TestCopyRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	c := NewClient(nil)
+	repo := newTestRepo(t)
+
+	layers := [][]byte{[]byte("base layer"), []byte("overlay layer")}
+	src := NewMemStore()
+	for i, data := range layers {
+		if err := src.Put(ctx, fmt.Sprintf("layer-%d", i), bytes.NewReader(data)); err != nil {
+			t.Fatalf("Put: %v", err)
+		}
+	}
+	if err := src.SetOrder(ctx, []string{"layer-0", "layer-1"}); err != nil {
+		t.Fatalf("SetOrder: %v", err)
+	}
+
+	srcRef := repo + ":src"
+	if _, err := c.Push(ctx, srcRef, src); err != nil {
+		t.Fatalf("Push: %v", err)
+	}
+
+	dstRef := repo + ":dst"
+	if _, err := c.Copy(ctx, srcRef, dstRef); err != nil {
+		t.Fatalf("Copy: %v", err)
+	}
+
+	srcDesc, err := c.Resolve(ctx, srcRef)
+	if err != nil {
+		t.Fatalf("Resolve(src): %v", err)
+	}
+	dstDesc, err := c.Resolve(ctx, dstRef)
+	if err != nil {
+		t.Fatalf("Resolve(dst): %v", err)
+	}
+
+	if !reflect.DeepEqual(srcDesc.Digest, dstDesc.Digest) {
+		t.Errorf("copied digest = %s, want %s", dstDesc.Digest, srcDesc.Digest)
+	}
+}
+
+// TestPullPushPreservesLayerMediaType pulls a real image with a
+// gzip-compressed layer and pushes it back out, asserting the layer's
+// original media type survives the round trip instead of being relabeled as
+// an uncompressed OCI layer.
+func TestPullPushPreservesLayerMediaType(t *testing.T) {
+	ctx := context.Background()
+	c := NewClient(nil)
+	repo := newTestRepo(t)
+
+	var gzipped bytes.Buffer
+	gw := gzip.NewWriter(&gzipped)
+	if _, err := gw.Write([]byte("docker layer contents")); err != nil {
+		t.Fatalf("gzip-compressing layer: %v", err)
+	}
+	if err := gw.Close(); err != nil {
+		t.Fatalf("closing gzip writer: %v", err)
+	}
+
+	img, err := mutate.AppendLayers(empty.Image, static.NewLayer(gzipped.Bytes(), types.DockerLayer))
+	if err != nil {
+		t.Fatalf("building image: %v", err)
+	}
+
+	srcRef := repo + ":src"
+	r, err := name.ParseReference(srcRef)
+	if err != nil {
+		t.Fatalf("parsing reference: %v", err)
+	}
+	if err := remote.Write(r, img); err != nil {
+		t.Fatalf("pushing image: %v", err)
+	}
+
+	mem := NewMemStore()
+	if _, err := c.Pull(ctx, srcRef, mem); err != nil {
+		t.Fatalf("Pull: %v", err)
+	}
+
+	dstRef := repo + ":dst"
+	if _, err := c.Push(ctx, dstRef, mem); err != nil {
+		t.Fatalf("Push: %v", err)
+	}
+
+	dstRefParsed, err := name.ParseReference(dstRef)
+	if err != nil {
+		t.Fatalf("parsing destination reference: %v", err)
+	}
+	dstImg, err := remote.Image(dstRefParsed)
+	if err != nil {
+		t.Fatalf("getting destination image: %v", err)
+	}
+
+	dstLayers, err := dstImg.Layers()
+	if err != nil {
+		t.Fatalf("getting destination layers: %v", err)
+	}
+	if len(dstLayers) != 1 {
+		t.Fatalf("got %d layers, want 1", len(dstLayers))
+	}
+
+	mt, err := dstLayers[0].MediaType()
+	if err != nil {
+		t.Fatalf("getting destination layer media type: %v", err)
+	}
+	if mt != types.DockerLayer {
+		t.Errorf("layer media type = %s, want %s", mt, types.DockerLayer)
+	}
+}
@@ -0,0 +1,104 @@
+package oci
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"os"
+
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/static"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+const cosignSimpleSigningMediaType = types.MediaType("application/vnd.dev.cosign.simplesigning.v1+json")
+
+// Sign computes a cosign-compatible simple-signing signature over the
+// manifest digest ref resolves to, using the ECDSA private key at keyPath,
+// and pushes it to the cosign-convention signature tag alongside the image.
+func (c *Client) Sign(ctx context.Context, ref, keyPath string) (Descriptor, error) {
+	desc, err := c.Resolve(ctx, ref)
+	if err != nil {
+		return Descriptor{}, err
+	}
+
+	payload, err := json.Marshal(simpleSigningPayloadFor(desc.Digest.String()))
+	if err != nil {
+		return Descriptor{}, fmt.Errorf("encoding simple-signing payload: %w", err)
+	}
+
+	priv, err := loadPrivateKey(keyPath)
+	if err != nil {
+		return Descriptor{}, err
+	}
+
+	digest := sha256.Sum256(payload)
+
+	sig, err := ecdsa.SignASN1(rand.Reader, priv, digest[:])
+	if err != nil {
+		return Descriptor{}, fmt.Errorf("signing: %w", err)
+	}
+
+	img, err := mutate.Append(empty.Image, mutate.Addendum{
+		Layer: static.NewLayer(payload, cosignSimpleSigningMediaType),
+		Annotations: map[string]string{
+			cosignSignatureAnnotation: base64.StdEncoding.EncodeToString(sig),
+		},
+	})
+	if err != nil {
+		return Descriptor{}, fmt.Errorf("building signature image: %w", err)
+	}
+
+	tag, err := sigTag(desc.Digest.String())
+	if err != nil {
+		return Descriptor{}, err
+	}
+
+	sigRef := desc.Ref.Context().Tag(tag)
+
+	if err := remote.Write(sigRef, img, c.options()...); err != nil {
+		return Descriptor{}, fmt.Errorf("pushing signature: %w", err)
+	}
+
+	sigDigest, err := img.Digest()
+	if err != nil {
+		return Descriptor{}, fmt.Errorf("getting signature digest: %w", err)
+	}
+
+	c.logger().Info(fmt.Sprintf("Signed %s, signature pushed to %s", desc.Digest, sigRef))
+
+	return Descriptor{Ref: sigRef, Digest: sigDigest}, nil
+}
+
+func simpleSigningPayloadFor(digest string) simpleSigningPayload {
+	var p simpleSigningPayload
+	p.Critical.Image.DockerManifestDigest = digest
+	return p
+}
+
+func loadPrivateKey(path string) (*ecdsa.PrivateKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading cosign private key: %w", err)
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM block found in %s", path)
+	}
+
+	key, err := x509.ParseECPrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("parsing EC private key: %w", err)
+	}
+
+	return key, nil
+}
@@ -0,0 +1,292 @@
+package oci
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Store is a named blob sink/source used by Client.Pull, Client.Push, and
+// Client.Copy so the registry-facing code never has to know whether the
+// other end is a directory on disk, an in-memory buffer, or something else.
+type Store interface {
+	// Put stores r under name, overwriting any existing blob with that name.
+	Put(ctx context.Context, name string, r io.Reader) error
+	// Get opens the blob stored under name.
+	Get(ctx context.Context, name string) (io.ReadCloser, error)
+	// List returns the names of all blobs currently in the store, sorted.
+	List(ctx context.Context) ([]string, error)
+	// SetOrder records names in the order their blobs should be replayed as
+	// image layers, e.g. the order Client.Pull read them off the source
+	// manifest. Push and Copy use this ordering instead of List's sort order.
+	SetOrder(ctx context.Context, names []string) error
+	// Order returns the names most recently recorded by SetOrder, or falls
+	// back to List if SetOrder has never been called on this store.
+	Order(ctx context.Context) ([]string, error)
+	// SetMediaType records the original media type of the blob stored under
+	// name, e.g. the layer's compressed media type as reported by the source
+	// manifest. Push uses this to republish the blob under its original media
+	// type instead of assuming it's uncompressed.
+	SetMediaType(ctx context.Context, name, mediaType string) error
+	// MediaType returns the media type most recently recorded by
+	// SetMediaType for name, or "" if none was recorded.
+	MediaType(ctx context.Context, name string) (string, error)
+}
+
+// FSStore is a Store backed by a directory on disk. Blob names are used
+// verbatim as filenames (e.g. a "sha256:<hex>" digest), so names containing
+// a path separator are rejected.
+type FSStore struct {
+	Dir string
+}
+
+// NewFSStore returns an FSStore rooted at dir, creating it if necessary.
+func NewFSStore(dir string) (*FSStore, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("creating store dir: %w", err)
+	}
+	return &FSStore{Dir: dir}, nil
+}
+
+func (s *FSStore) path(name string) (string, error) {
+	if name == "" || name == "." || name == ".." || strings.ContainsAny(name, "/\\") {
+		return "", fmt.Errorf("invalid blob name %q", name)
+	}
+	return filepath.Join(s.Dir, name), nil
+}
+
+func (s *FSStore) Put(_ context.Context, name string, r io.Reader) error {
+	path, err := s.path(name)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = io.Copy(f, r)
+	return err
+}
+
+func (s *FSStore) Get(_ context.Context, name string) (io.ReadCloser, error) {
+	path, err := s.path(name)
+	if err != nil {
+		return nil, err
+	}
+	return os.Open(path)
+}
+
+func (s *FSStore) List(_ context.Context) ([]string, error) {
+	entries, err := os.ReadDir(s.Dir)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(entries))
+	for _, e := range entries {
+		if e.IsDir() || strings.HasPrefix(e.Name(), ".") {
+			continue
+		}
+		names = append(names, e.Name())
+	}
+	sort.Strings(names)
+
+	return names, nil
+}
+
+// orderPath is a dotfile so it's excluded from List.
+func (s *FSStore) orderPath() string {
+	return filepath.Join(s.Dir, ".order.json")
+}
+
+func (s *FSStore) SetOrder(_ context.Context, names []string) error {
+	data, err := json.Marshal(names)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(s.orderPath(), data, 0644)
+}
+
+func (s *FSStore) Order(ctx context.Context) ([]string, error) {
+	data, err := os.ReadFile(s.orderPath())
+	if errors.Is(err, os.ErrNotExist) {
+		return s.List(ctx)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	if err := json.Unmarshal(data, &names); err != nil {
+		return nil, fmt.Errorf("parsing stored layer order: %w", err)
+	}
+
+	return names, nil
+}
+
+// mediaTypesPath is a dotfile so it's excluded from List.
+func (s *FSStore) mediaTypesPath() string {
+	return filepath.Join(s.Dir, ".mediatypes.json")
+}
+
+func (s *FSStore) readMediaTypes() (map[string]string, error) {
+	data, err := os.ReadFile(s.mediaTypesPath())
+	if errors.Is(err, os.ErrNotExist) {
+		return map[string]string{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	mediaTypes := map[string]string{}
+	if err := json.Unmarshal(data, &mediaTypes); err != nil {
+		return nil, fmt.Errorf("parsing stored media types: %w", err)
+	}
+
+	return mediaTypes, nil
+}
+
+// SetMediaType is called once per layer during Pull, so the read-modify-write
+// against .mediatypes.json is written through a temp file and renamed into
+// place (like orderPath's single write, but here done atomically per-call)
+// to avoid ever leaving a truncated, unparseable file behind if the process
+// is killed mid-write.
+func (s *FSStore) SetMediaType(_ context.Context, name, mediaType string) error {
+	mediaTypes, err := s.readMediaTypes()
+	if err != nil {
+		return err
+	}
+
+	mediaTypes[name] = mediaType
+
+	data, err := json.Marshal(mediaTypes)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(s.Dir, ".mediatypes-*.tmp")
+	if err != nil {
+		return fmt.Errorf("creating temp file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("writing media types: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("closing temp file: %w", err)
+	}
+
+	if err := os.Rename(tmp.Name(), s.mediaTypesPath()); err != nil {
+		return fmt.Errorf("renaming media types into place: %w", err)
+	}
+
+	return nil
+}
+
+func (s *FSStore) MediaType(_ context.Context, name string) (string, error) {
+	mediaTypes, err := s.readMediaTypes()
+	if err != nil {
+		return "", err
+	}
+	return mediaTypes[name], nil
+}
+
+// MemStore is an in-memory Store, useful for Client.Copy and for tests
+// backed by a fake registry.
+type MemStore struct {
+	mu         sync.RWMutex
+	blobs      map[string][]byte
+	order      []string
+	mediaTypes map[string]string
+}
+
+// NewMemStore returns an empty in-memory Store.
+func NewMemStore() *MemStore {
+	return &MemStore{blobs: map[string][]byte{}, mediaTypes: map[string]string{}}
+}
+
+func (s *MemStore) Put(_ context.Context, name string, r io.Reader) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.blobs[name] = data
+
+	return nil
+}
+
+func (s *MemStore) Get(_ context.Context, name string) (io.ReadCloser, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	data, ok := s.blobs[name]
+	if !ok {
+		return nil, fmt.Errorf("blob %q not found", name)
+	}
+
+	return io.NopCloser(bytes.NewReader(data)), nil
+}
+
+func (s *MemStore) List(_ context.Context) ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	names := make([]string, 0, len(s.blobs))
+	for name := range s.blobs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names, nil
+}
+
+func (s *MemStore) SetOrder(_ context.Context, names []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.order = append(make([]string, 0, len(names)), names...)
+	return nil
+}
+
+func (s *MemStore) Order(ctx context.Context) ([]string, error) {
+	s.mu.RLock()
+	order := s.order
+	s.mu.RUnlock()
+
+	if order == nil {
+		return s.List(ctx)
+	}
+
+	return append([]string(nil), order...), nil
+}
+
+func (s *MemStore) SetMediaType(_ context.Context, name, mediaType string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.mediaTypes[name] = mediaType
+	return nil
+}
+
+func (s *MemStore) MediaType(_ context.Context, name string) (string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.mediaTypes[name], nil
+}
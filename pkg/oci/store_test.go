@@ -0,0 +1,57 @@
+package oci
+
+import (
+	"context"
+	"testing"
+)
+
+func TestFSStoreMediaTypePersistence(t *testing.T) {
+	ctx := context.Background()
+	s, err := NewFSStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewFSStore: %v", err)
+	}
+
+	mt, err := s.MediaType(ctx, "layer-0")
+	if err != nil {
+		t.Fatalf("MediaType: %v", err)
+	}
+	if mt != "" {
+		t.Errorf("MediaType for an unset name = %q, want empty", mt)
+	}
+
+	if err := s.SetMediaType(ctx, "layer-0", "application/vnd.docker.image.rootfs.diff.tar.gzip"); err != nil {
+		t.Fatalf("SetMediaType: %v", err)
+	}
+	if err := s.SetMediaType(ctx, "layer-1", "application/vnd.oci.image.layer.v1.tar"); err != nil {
+		t.Fatalf("SetMediaType: %v", err)
+	}
+
+	mt, err = s.MediaType(ctx, "layer-0")
+	if err != nil {
+		t.Fatalf("MediaType: %v", err)
+	}
+	if mt != "application/vnd.docker.image.rootfs.diff.tar.gzip" {
+		t.Errorf("MediaType(layer-0) = %q, want docker gzip layer", mt)
+	}
+
+	// Re-reading through a second FSStore pointed at the same directory
+	// confirms the media types actually persisted to disk rather than only
+	// living in process memory.
+	reopened := &FSStore{Dir: s.Dir}
+	mt, err = reopened.MediaType(ctx, "layer-1")
+	if err != nil {
+		t.Fatalf("MediaType: %v", err)
+	}
+	if mt != "application/vnd.oci.image.layer.v1.tar" {
+		t.Errorf("MediaType(layer-1) after reopening = %q, want OCI layer", mt)
+	}
+
+	names, err := s.List(ctx)
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(names) != 0 {
+		t.Errorf("List returned %v, want empty (media types dotfile should not be listed as a blob)", names)
+	}
+}
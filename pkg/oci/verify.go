@@ -0,0 +1,503 @@
+package oci
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	gcrv1 "github.com/google/go-containerregistry/pkg/v1"
+)
+
+// cosign's well-known annotation keys on a simple-signing layer descriptor.
+const (
+	cosignSignatureAnnotation   = "dev.cosignproject.cosign/signature"
+	cosignCertificateAnnotation = "dev.sigstore.cosign/certificate"
+	cosignBundleAnnotation      = "dev.sigstore.cosign/bundle"
+)
+
+// rekorPublicKeyPEM is the PEM-encoded ECDSA public key of the production
+// Rekor transparency log run by the Sigstore public-good instance
+// (rekor.sigstore.dev), used to verify a signature's inclusion proof
+// (SignedEntryTimestamp) when verifying a keyless signature. Callers
+// targeting a private Rekor instance should override it via
+// VerifyOptions.RekorPublicKeyPath.
+const rekorPublicKeyPEM = `-----BEGIN PUBLIC KEY-----
+MFkwEwYHKoZIzj0CAQYIKoZIzj0DAQcDQgAE2G2Y+2tabdTV5BcGiBIx0a9fAFwr
+kBbmLSGtks4L3qX6yYY0zufBnhC8Ur/iy55GhWP/9A/bY2LhC30M9+RYtw==
+-----END PUBLIC KEY-----`
+
+// fulcioRootPEM is the PEM-encoded certificate chain (root followed by the
+// currently-active intermediate) of the Fulcio CA run by the Sigstore
+// public-good instance, used to confirm a keyless signing certificate was
+// actually issued by Fulcio rather than self-signed by an attacker. Callers
+// targeting a private Fulcio instance should override it via
+// VerifyOptions.FulcioRootPath.
+const fulcioRootPEM = `-----BEGIN CERTIFICATE-----
+MIIB9zCCAXygAwIBAgIUALZNAPFdxHPwjeDloDwyYChAO/4wCgYIKoZIzj0EAwMw
+KjEVMBMGA1UEChMMc2lnc3RvcmUuZGV2MREwDwYDVQQDEwhzaWdzdG9yZTAeFw0y
+MTEwMDcxMzU2NTlaFw0zMTEwMDUxMzU2NThaMCoxFTATBgNVBAoTDHNpZ3N0b3Jl
+LmRldjERMA8GA1UEAxMIc2lnc3RvcmUwdjAQBgcqhkjOPQIBBgUrgQQAIgNiAAT7
+XeFT4rb3PQGwS4IajtLk3/OlnpgangaBclYpsYBr5i+4ynB07ceb3LP0OIOZdxex
+X69c5iVuyJRQ+Hz05yi+UF3uBWAlHpiS5sh0+H2GHE7SXrk1EC5m1Tr19L9gg92j
+YzBhMA4GA1UdDwEB/wQEAwIBBjAPBgNVHRMBAf8EBTADAQH/MB0GA1UdDgQWBBRY
+wB5fkUWlZql6zJChkyLQKsXF+jAfBgNVHSMEGDAWgBRYwB5fkUWlZql6zJChkyLQ
+KsXF+jAKBggqhkjOPQQDAwNpADBmAjEAj1nHeXZp+13NWBNa+EDsDP8G1WWg1tCM
+WP/WHPqpaVo0jhsweNFZgSs0eE7wYI4qAjEA2WB9ot98sIkoF3vZYdd3/VtWB5b9
+TNMea7Ix/stJ5TfcLLeABLE4BNJOsQ4vnBHJ
+-----END CERTIFICATE-----
+-----BEGIN CERTIFICATE-----
+MIICGjCCAaGgAwIBAgIUALnViVfnU0brJasmRkHrn/UnfaQwCgYIKoZIzj0EAwMw
+KjEVMBMGA1UEChMMc2lnc3RvcmUuZGV2MREwDwYDVQQDEwhzaWdzdG9yZTAeFw0y
+MjA0MTMyMDA2MTVaFw0zMTEwMDUxMzU2NThaMDcxFTATBgNVBAoTDHNpZ3N0b3Jl
+LmRldjEeMBwGA1UEAxMVc2lnc3RvcmUtaW50ZXJtZWRpYXRlMHYwEAYHKoZIzj0C
+AQYFK4EEACIDYgAE8RVS/ysH+NOvuDZyPIZtilgUF9NlarYpAd9HP1vBBH1U5CV7
+7LSS7s0ZiH4nE7Hv7ptS6LvvR/STk798LVgMzLlJ4HeIfF3tHSaexLcYpSASr1kS
+0N/RgBJz/9jWCiXno3sweTAOBgNVHQ8BAf8EBAMCAQYwEwYDVR0lBAwwCgYIKwYB
+BQUHAwMwEgYDVR0TAQH/BAgwBgEB/wIBADAdBgNVHQ4EFgQU39Ppz1YkEZb5qNjp
+KFWixi4YZD8wHwYDVR0jBBgwFoAUWMAeX5FFpWapesyQoZMi0CrFxfowCgYIKoZI
+zj0EAwMDZwAwZAIwPCsQK4DYiZYDPIaDi5HFKnfxXx6ASSVmERfsynYBiX2X6SJR
+nZU84/9DZdnFvvxmAjBOt6QpBlc4J/0DxvkTCqpclvziL6BCCPnjdlIB3Pu3BxsP
+mygUY7Ii2zbdCdliiow=
+-----END CERTIFICATE-----`
+
+// VerifyOptions configures signature verification for Client.VerifySignature.
+type VerifyOptions struct {
+	// CosignKeyPath is the path to a PEM-encoded ECDSA public key used for
+	// key-based verification. Mutually exclusive with Identity/Issuer.
+	CosignKeyPath string
+	// Identity and Issuer select keyless verification: the signing
+	// certificate's SAN and Fulcio issuer extension must match these
+	// values exactly.
+	Identity string
+	Issuer   string
+	// RekorPublicKeyPath overrides the pinned Rekor public key used to
+	// verify a keyless signature's transparency-log inclusion proof, with
+	// the path to a PEM-encoded ECDSA public key.
+	RekorPublicKeyPath string
+	// FulcioRootPath overrides the pinned Fulcio root/intermediate
+	// certificate pool used to validate a keyless signing certificate's
+	// chain of trust, with the path to a PEM-encoded certificate bundle.
+	FulcioRootPath string
+}
+
+// VerifiedSubject describes the digest and signer a signature was verified
+// against.
+type VerifiedSubject struct {
+	Digest string
+	Signer string
+}
+
+// simpleSigningPayload is the JSON payload cosign signs over: a reference to
+// the subject's digest (plus optional annotations, which we don't need).
+type simpleSigningPayload struct {
+	Critical struct {
+		Image struct {
+			DockerManifestDigest string `json:"docker-manifest-digest"`
+		} `json:"image"`
+	} `json:"critical"`
+}
+
+// rekorBundle is the subset of cosign's "dev.sigstore.cosign/bundle"
+// annotation needed to verify the Rekor SET.
+type rekorBundle struct {
+	SignedEntryTimestamp string `json:"SignedEntryTimestamp"`
+	Payload              struct {
+		Body           string `json:"body"`
+		IntegratedTime int64  `json:"integratedTime"`
+		LogIndex       int64  `json:"logIndex"`
+		LogID          string `json:"logID"`
+	} `json:"Payload"`
+}
+
+// hashedRekordEntry is the subset of a Rekor "hashedrekord" entry body
+// needed to cross-check it against the signature being verified: the
+// artifact hash and the signature/certificate the entry was logged for must
+// match the ones we're verifying, or an unrelated, legitimately-logged entry
+// could be spliced onto a forged signature.
+type hashedRekordEntry struct {
+	Kind string `json:"kind"`
+	Spec struct {
+		Data struct {
+			Hash struct {
+				Algorithm string `json:"algorithm"`
+				Value     string `json:"value"`
+			} `json:"hash"`
+		} `json:"data"`
+		Signature struct {
+			Content   string `json:"content"`
+			PublicKey struct {
+				Content string `json:"content"`
+			} `json:"publicKey"`
+		} `json:"signature"`
+	} `json:"spec"`
+}
+
+// sigTag returns the cosign-convention tag for the signature of digest,
+// e.g. "sha256-<hex>.sig".
+func sigTag(digest string) (string, error) {
+	alg, hex, ok := strings.Cut(digest, ":")
+	if !ok {
+		return "", fmt.Errorf("malformed digest %q", digest)
+	}
+	return fmt.Sprintf("%s-%s.sig", alg, hex), nil
+}
+
+// VerifySignature discovers the cosign signature artifact for ref's
+// resolved digest and validates it, either against a user-supplied public
+// key or a keyless Fulcio certificate plus its Rekor inclusion proof. It
+// returns an error if no matching signature is found or verification fails.
+func (c *Client) VerifySignature(ctx context.Context, ref string, opts VerifyOptions) (VerifiedSubject, error) {
+	desc, err := c.Resolve(ctx, ref)
+	if err != nil {
+		return VerifiedSubject{}, err
+	}
+
+	tag, err := sigTag(desc.Digest.String())
+	if err != nil {
+		return VerifiedSubject{}, err
+	}
+
+	sigRef := desc.Ref.Context().Tag(tag)
+
+	sigImg, _, err := c.Image(ctx, sigRef.Name())
+	if err != nil {
+		return VerifiedSubject{}, fmt.Errorf("no signature found at %s: %w", sigRef, err)
+	}
+
+	manifest, err := sigImg.Manifest()
+	if err != nil {
+		return VerifiedSubject{}, fmt.Errorf("reading signature manifest: %w", err)
+	}
+
+	layers, err := sigImg.Layers()
+	if err != nil {
+		return VerifiedSubject{}, fmt.Errorf("reading signature layers: %w", err)
+	}
+
+	if len(manifest.Layers) != len(layers) {
+		return VerifiedSubject{}, fmt.Errorf("signature manifest layer count mismatch")
+	}
+
+	var lastErr error
+	for i, layer := range layers {
+		subject, err := verifyOneSignature(layer, manifest.Layers[i].Annotations, desc.Digest.String(), opts)
+		if err == nil {
+			return subject, nil
+		}
+		lastErr = err
+	}
+
+	return VerifiedSubject{}, fmt.Errorf("no valid signature for %s: %w", desc.Digest, lastErr)
+}
+
+func verifyOneSignature(layer gcrv1.Layer, annotations map[string]string, wantDigest string, opts VerifyOptions) (VerifiedSubject, error) {
+	sigB64, ok := annotations[cosignSignatureAnnotation]
+	if !ok {
+		return VerifiedSubject{}, fmt.Errorf("signature layer missing %s annotation", cosignSignatureAnnotation)
+	}
+
+	sig, err := base64.StdEncoding.DecodeString(sigB64)
+	if err != nil {
+		return VerifiedSubject{}, fmt.Errorf("decoding signature: %w", err)
+	}
+
+	blob, err := layer.Compressed()
+	if err != nil {
+		return VerifiedSubject{}, fmt.Errorf("reading signature payload: %w", err)
+	}
+	defer blob.Close()
+
+	payload, err := io.ReadAll(blob)
+	if err != nil {
+		return VerifiedSubject{}, fmt.Errorf("reading signature payload: %w", err)
+	}
+
+	var simple simpleSigningPayload
+	if err := json.Unmarshal(payload, &simple); err != nil {
+		return VerifiedSubject{}, fmt.Errorf("parsing simple-signing payload: %w", err)
+	}
+	if simple.Critical.Image.DockerManifestDigest != wantDigest {
+		return VerifiedSubject{}, fmt.Errorf("signature is for digest %s, not %s", simple.Critical.Image.DockerManifestDigest, wantDigest)
+	}
+
+	var pub crypto.PublicKey
+	var signer string
+
+	switch {
+	case opts.CosignKeyPath != "":
+		pub, err = loadPublicKey(opts.CosignKeyPath)
+		if err != nil {
+			return VerifiedSubject{}, err
+		}
+		signer = opts.CosignKeyPath
+	default:
+		certPEM, ok := annotations[cosignCertificateAnnotation]
+		if !ok {
+			return VerifiedSubject{}, fmt.Errorf("keyless verification requires a %s annotation", cosignCertificateAnnotation)
+		}
+
+		cert, err := parseCertificate(certPEM)
+		if err != nil {
+			return VerifiedSubject{}, err
+		}
+
+		if err := checkIdentity(cert, opts.Identity, opts.Issuer); err != nil {
+			return VerifiedSubject{}, err
+		}
+
+		rekorKeyPEM, err := rekorKey(opts.RekorPublicKeyPath)
+		if err != nil {
+			return VerifiedSubject{}, err
+		}
+
+		integratedAt, err := verifyRekorInclusion(annotations[cosignBundleAnnotation], cert, payload, sig, rekorKeyPEM)
+		if err != nil {
+			return VerifiedSubject{}, fmt.Errorf("verifying Rekor inclusion: %w", err)
+		}
+
+		rootsPEM, err := fulcioRoots(opts.FulcioRootPath)
+		if err != nil {
+			return VerifiedSubject{}, err
+		}
+
+		if err := verifyFulcioChain(cert, rootsPEM, integratedAt); err != nil {
+			return VerifiedSubject{}, err
+		}
+
+		pub = cert.PublicKey
+		signer = opts.Identity
+	}
+
+	ecPub, ok := pub.(*ecdsa.PublicKey)
+	if !ok {
+		return VerifiedSubject{}, fmt.Errorf("unsupported public key type %T", pub)
+	}
+
+	digest := sha256.Sum256(payload)
+	if !ecdsa.VerifyASN1(ecPub, digest[:], sig) {
+		return VerifiedSubject{}, fmt.Errorf("signature verification failed")
+	}
+
+	return VerifiedSubject{Digest: wantDigest, Signer: signer}, nil
+}
+
+func loadPublicKey(path string) (crypto.PublicKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading cosign key: %w", err)
+	}
+
+	return loadPublicKeyPEM(string(data))
+}
+
+func parseCertificate(certPEM string) (*x509.Certificate, error) {
+	block, _ := pem.Decode([]byte(certPEM))
+	if block == nil {
+		return nil, fmt.Errorf("no PEM block found in signing certificate")
+	}
+
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("parsing signing certificate: %w", err)
+	}
+
+	return cert, nil
+}
+
+// fulcioIssuerOID is the x509 extension cosign's Fulcio CA embeds the OIDC
+// issuer in.
+var fulcioIssuerOID = []int{1, 3, 6, 1, 4, 1, 57264, 1, 1}
+
+func checkIdentity(cert *x509.Certificate, wantIdentity, wantIssuer string) error {
+	if wantIdentity == "" || wantIssuer == "" {
+		return fmt.Errorf("keyless verification requires --cosign-identity and --cosign-issuer")
+	}
+
+	matched := false
+	for _, san := range cert.URIs {
+		if san.String() == wantIdentity {
+			matched = true
+			break
+		}
+	}
+	for _, san := range cert.EmailAddresses {
+		if san == wantIdentity {
+			matched = true
+			break
+		}
+	}
+	if !matched {
+		return fmt.Errorf("certificate identity does not match %q", wantIdentity)
+	}
+
+	for _, ext := range cert.Extensions {
+		if ext.Id.Equal(fulcioIssuerOID) && string(ext.Value) == wantIssuer {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("certificate issuer does not match %q", wantIssuer)
+}
+
+func rekorKey(overridePath string) (string, error) {
+	if overridePath == "" {
+		return rekorPublicKeyPEM, nil
+	}
+
+	data, err := os.ReadFile(overridePath)
+	if err != nil {
+		return "", fmt.Errorf("reading Rekor public key: %w", err)
+	}
+
+	return string(data), nil
+}
+
+// rekorSETPayload returns the bytes a Rekor SignedEntryTimestamp signs: the
+// canonical JSON (sorted keys, no whitespace) of the bundle payload, with the
+// entry body still base64-encoded.
+func rekorSETPayload(bundle rekorBundle) ([]byte, error) {
+	return json.Marshal(map[string]interface{}{
+		"body":           bundle.Payload.Body,
+		"integratedTime": bundle.Payload.IntegratedTime,
+		"logIndex":       bundle.Payload.LogIndex,
+		"logID":          bundle.Payload.LogID,
+	})
+}
+
+// verifyRekorInclusion verifies that bundleAnnotation's SignedEntryTimestamp
+// is a valid ECDSA signature, by the holder of rekorKeyPEM, over the
+// canonicalized bundle payload (see rekorSETPayload), then cross-checks the entry itself
+// (artifact hash, signature, and certificate) against payload/sig/cert so an
+// unrelated, legitimately-logged Rekor entry can't be spliced onto a forged
+// signature. It returns the entry's logged integration time, used as the
+// reference time for validating cert's Fulcio chain.
+func verifyRekorInclusion(bundleAnnotation string, cert *x509.Certificate, payload, sig []byte, rekorKeyPEM string) (time.Time, error) {
+	if bundleAnnotation == "" {
+		return time.Time{}, fmt.Errorf("keyless signature missing %s annotation", cosignBundleAnnotation)
+	}
+
+	var bundle rekorBundle
+	if err := json.Unmarshal([]byte(bundleAnnotation), &bundle); err != nil {
+		return time.Time{}, fmt.Errorf("parsing Rekor bundle: %w", err)
+	}
+
+	set, err := base64.StdEncoding.DecodeString(bundle.SignedEntryTimestamp)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("decoding SignedEntryTimestamp: %w", err)
+	}
+
+	body, err := base64.StdEncoding.DecodeString(bundle.Payload.Body)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("decoding Rekor entry body: %w", err)
+	}
+
+	pub, err := loadPublicKeyPEM(rekorKeyPEM)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("loading Rekor public key: %w", err)
+	}
+
+	ecPub, ok := pub.(*ecdsa.PublicKey)
+	if !ok {
+		return time.Time{}, fmt.Errorf("unsupported Rekor public key type %T", pub)
+	}
+
+	setPayload, err := rekorSETPayload(bundle)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("encoding Rekor bundle payload: %w", err)
+	}
+
+	digest := sha256.Sum256(setPayload)
+	if !ecdsa.VerifyASN1(ecPub, digest[:], set) {
+		return time.Time{}, fmt.Errorf("SignedEntryTimestamp does not verify against the pinned Rekor key")
+	}
+
+	var entry hashedRekordEntry
+	if err := json.Unmarshal(body, &entry); err != nil {
+		return time.Time{}, fmt.Errorf("parsing Rekor entry body: %w", err)
+	}
+	if entry.Kind != "hashedrekord" {
+		return time.Time{}, fmt.Errorf("unsupported Rekor entry kind %q", entry.Kind)
+	}
+
+	wantHash := sha256.Sum256(payload)
+	if !strings.EqualFold(entry.Spec.Data.Hash.Value, hex.EncodeToString(wantHash[:])) {
+		return time.Time{}, fmt.Errorf("Rekor entry artifact hash does not match the signed payload")
+	}
+
+	entrySig, err := base64.StdEncoding.DecodeString(entry.Spec.Signature.Content)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("decoding Rekor entry signature: %w", err)
+	}
+	if !bytes.Equal(entrySig, sig) {
+		return time.Time{}, fmt.Errorf("Rekor entry signature does not match the image's signature")
+	}
+
+	entryCertPEM, err := base64.StdEncoding.DecodeString(entry.Spec.Signature.PublicKey.Content)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("decoding Rekor entry certificate: %w", err)
+	}
+	entryCert, err := parseCertificate(string(entryCertPEM))
+	if err != nil {
+		return time.Time{}, fmt.Errorf("parsing Rekor entry certificate: %w", err)
+	}
+	if !bytes.Equal(entryCert.Raw, cert.Raw) {
+		return time.Time{}, fmt.Errorf("Rekor entry certificate does not match the signing certificate")
+	}
+
+	return time.Unix(bundle.Payload.IntegratedTime, 0), nil
+}
+
+func fulcioRoots(overridePath string) (string, error) {
+	if overridePath == "" {
+		return fulcioRootPEM, nil
+	}
+
+	data, err := os.ReadFile(overridePath)
+	if err != nil {
+		return "", fmt.Errorf("reading Fulcio root pool: %w", err)
+	}
+
+	return string(data), nil
+}
+
+// verifyFulcioChain confirms cert chains up to a trusted Fulcio root,
+// treating it as valid at integratedAt (the time Rekor logged the
+// signature), since Fulcio certificates are only valid for the few minutes
+// around when they were issued.
+func verifyFulcioChain(cert *x509.Certificate, rootsPEM string, integratedAt time.Time) error {
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM([]byte(rootsPEM)) {
+		return fmt.Errorf("parsing Fulcio root pool")
+	}
+
+	_, err := cert.Verify(x509.VerifyOptions{
+		Roots:       pool,
+		KeyUsages:   []x509.ExtKeyUsage{x509.ExtKeyUsageCodeSigning},
+		CurrentTime: integratedAt,
+	})
+	if err != nil {
+		return fmt.Errorf("certificate does not chain to a trusted Fulcio root: %w", err)
+	}
+
+	return nil
+}
+
+func loadPublicKeyPEM(keyPEM string) (crypto.PublicKey, error) {
+	block, _ := pem.Decode([]byte(keyPEM))
+	if block == nil {
+		return nil, fmt.Errorf("no PEM block found")
+	}
+	return x509.ParsePKIXPublicKey(block.Bytes)
+}
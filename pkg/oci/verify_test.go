@@ -0,0 +1,321 @@
+package oci
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/static"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+// TestSigstoreTrustRootConstantsParse guards against the pinned Rekor/Fulcio
+// constants bit-rotting into something that fails to parse, which would
+// silently break every keyless --verify call before any cert or Rekor logic
+// even runs.
+func TestSigstoreTrustRootConstantsParse(t *testing.T) {
+	if _, err := loadPublicKeyPEM(rekorPublicKeyPEM); err != nil {
+		t.Errorf("rekorPublicKeyPEM does not parse as an ECDSA public key: %v", err)
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM([]byte(fulcioRootPEM)) {
+		t.Error("fulcioRootPEM does not parse as a PEM certificate bundle")
+	}
+}
+
+func writeTempPEM(t *testing.T, blockType string, der []byte) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), blockType+".pem")
+	data := pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der})
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatalf("writing %s: %v", path, err)
+	}
+	return path
+}
+
+func pushTrivialImage(t *testing.T, ref string) {
+	t.Helper()
+
+	img, err := mutate.AppendLayers(empty.Image, static.NewLayer([]byte("hello"), types.OCILayer))
+	if err != nil {
+		t.Fatalf("building image: %v", err)
+	}
+
+	r, err := name.ParseReference(ref)
+	if err != nil {
+		t.Fatalf("parsing reference: %v", err)
+	}
+	if err := remote.Write(r, img); err != nil {
+		t.Fatalf("pushing image: %v", err)
+	}
+}
+
+func TestSignThenVerifySignatureKeyBased(t *testing.T) {
+	ctx := context.Background()
+	c := NewClient(nil)
+	ref := newTestRepo(t) + ":v1"
+
+	pushTrivialImage(t, ref)
+
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generating key: %v", err)
+	}
+
+	privDER, err := x509.MarshalECPrivateKey(priv)
+	if err != nil {
+		t.Fatalf("marshaling private key: %v", err)
+	}
+	keyPath := writeTempPEM(t, "EC PRIVATE KEY", privDER)
+
+	pubDER, err := x509.MarshalPKIXPublicKey(&priv.PublicKey)
+	if err != nil {
+		t.Fatalf("marshaling public key: %v", err)
+	}
+	pubPath := writeTempPEM(t, "PUBLIC KEY", pubDER)
+
+	if _, err := c.Sign(ctx, ref, keyPath); err != nil {
+		t.Fatalf("Sign: %v", err)
+	}
+
+	desc, err := c.Resolve(ctx, ref)
+	if err != nil {
+		t.Fatalf("Resolve: %v", err)
+	}
+
+	subject, err := c.VerifySignature(ctx, ref, VerifyOptions{CosignKeyPath: pubPath})
+	if err != nil {
+		t.Fatalf("VerifySignature: %v", err)
+	}
+	if subject.Digest != desc.Digest.String() {
+		t.Errorf("verified digest = %s, want %s", subject.Digest, desc.Digest)
+	}
+}
+
+// TestVerifySignatureKeylessRoundTrip exercises the full keyless path against
+// a locally-issued stand-in for the Fulcio/Rekor infrastructure (using
+// VerifyOptions.FulcioRootPath/RekorPublicKeyPath), since minting a
+// certificate that chains to the real, pinned production Fulcio root
+// requires Fulcio's private key. It would have caught the original
+// implementation only string-comparing the certificate's own claimed
+// identity, and the Rekor bundle not being cross-checked against the actual
+// signature/certificate.
+func TestVerifySignatureKeylessRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	c := NewClient(nil)
+	ref := newTestRepo(t) + ":v1"
+
+	pushTrivialImage(t, ref)
+
+	desc, err := c.Resolve(ctx, ref)
+	if err != nil {
+		t.Fatalf("Resolve: %v", err)
+	}
+
+	integratedAt := time.Now()
+
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generating CA key: %v", err)
+	}
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{Organization: []string{"test-fulcio"}},
+		NotBefore:             integratedAt.Add(-time.Hour),
+		NotAfter:              integratedAt.Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatalf("creating CA certificate: %v", err)
+	}
+	caCert, err := x509.ParseCertificate(caDER)
+	if err != nil {
+		t.Fatalf("parsing CA certificate: %v", err)
+	}
+	caPath := writeTempPEM(t, "CERTIFICATE", caDER)
+
+	const identity = "tester@example.com"
+	const issuer = "https://issuer.example.com"
+
+	leafKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generating leaf key: %v", err)
+	}
+	leafTemplate := &x509.Certificate{
+		SerialNumber:   big.NewInt(2),
+		NotBefore:      integratedAt.Add(-time.Minute),
+		NotAfter:       integratedAt.Add(time.Minute),
+		EmailAddresses: []string{identity},
+		KeyUsage:       x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:    []x509.ExtKeyUsage{x509.ExtKeyUsageCodeSigning},
+		ExtraExtensions: []pkix.Extension{
+			{Id: asn1.ObjectIdentifier(fulcioIssuerOID), Value: []byte(issuer)},
+		},
+	}
+	leafDER, err := x509.CreateCertificate(rand.Reader, leafTemplate, caCert, &leafKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatalf("creating leaf certificate: %v", err)
+	}
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: leafDER})
+
+	payload, err := json.Marshal(simpleSigningPayloadFor(desc.Digest.String()))
+	if err != nil {
+		t.Fatalf("marshaling simple-signing payload: %v", err)
+	}
+	payloadHash := sha256.Sum256(payload)
+	sig, err := ecdsa.SignASN1(rand.Reader, leafKey, payloadHash[:])
+	if err != nil {
+		t.Fatalf("signing payload: %v", err)
+	}
+
+	rekorKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generating Rekor key: %v", err)
+	}
+	rekorPubDER, err := x509.MarshalPKIXPublicKey(&rekorKey.PublicKey)
+	if err != nil {
+		t.Fatalf("marshaling Rekor public key: %v", err)
+	}
+	rekorPubPath := writeTempPEM(t, "PUBLIC KEY", rekorPubDER)
+
+	var entry hashedRekordEntry
+	entry.Kind = "hashedrekord"
+	entry.Spec.Data.Hash.Algorithm = "sha256"
+	entry.Spec.Data.Hash.Value = hex.EncodeToString(payloadHash[:])
+	entry.Spec.Signature.Content = base64.StdEncoding.EncodeToString(sig)
+	entry.Spec.Signature.PublicKey.Content = base64.StdEncoding.EncodeToString(certPEM)
+
+	body, err := json.Marshal(entry)
+	if err != nil {
+		t.Fatalf("marshaling Rekor entry: %v", err)
+	}
+	var bundle rekorBundle
+	bundle.Payload.Body = base64.StdEncoding.EncodeToString(body)
+	bundle.Payload.IntegratedTime = integratedAt.Unix()
+	setPayload, err := rekorSETPayload(bundle)
+	if err != nil {
+		t.Fatalf("encoding Rekor bundle payload: %v", err)
+	}
+	setHash := sha256.Sum256(setPayload)
+	set, err := ecdsa.SignASN1(rand.Reader, rekorKey, setHash[:])
+	if err != nil {
+		t.Fatalf("signing Rekor bundle payload: %v", err)
+	}
+	bundle.SignedEntryTimestamp = base64.StdEncoding.EncodeToString(set)
+	bundleJSON, err := json.Marshal(bundle)
+	if err != nil {
+		t.Fatalf("marshaling Rekor bundle: %v", err)
+	}
+
+	sigImg, err := mutate.Append(empty.Image, mutate.Addendum{
+		Layer: static.NewLayer(payload, cosignSimpleSigningMediaType),
+		Annotations: map[string]string{
+			cosignSignatureAnnotation:   base64.StdEncoding.EncodeToString(sig),
+			cosignCertificateAnnotation: string(certPEM),
+			cosignBundleAnnotation:      string(bundleJSON),
+		},
+	})
+	if err != nil {
+		t.Fatalf("building signature image: %v", err)
+	}
+
+	tag, err := sigTag(desc.Digest.String())
+	if err != nil {
+		t.Fatalf("sigTag: %v", err)
+	}
+	sigRef := desc.Ref.Context().Tag(tag)
+	if err := remote.Write(sigRef, sigImg); err != nil {
+		t.Fatalf("pushing signature: %v", err)
+	}
+
+	subject, err := c.VerifySignature(ctx, ref, VerifyOptions{
+		Identity:           identity,
+		Issuer:             issuer,
+		RekorPublicKeyPath: rekorPubPath,
+		FulcioRootPath:     caPath,
+	})
+	if err != nil {
+		t.Fatalf("VerifySignature: %v", err)
+	}
+	if subject.Digest != desc.Digest.String() {
+		t.Errorf("verified digest = %s, want %s", subject.Digest, desc.Digest)
+	}
+
+	// A Rekor entry logged for a different artifact must not verify,
+	// confirming the entry is actually cross-checked against the signature
+	// rather than merely having a valid SET.
+	t.Run("entry for a different artifact is rejected", func(t *testing.T) {
+		tamperedEntry := entry
+		tamperedEntry.Spec.Data.Hash.Value = fmt.Sprintf("%x", sha256.Sum256([]byte("not the payload")))
+
+		tamperedBody, err := json.Marshal(tamperedEntry)
+		if err != nil {
+			t.Fatalf("marshaling tampered entry: %v", err)
+		}
+		var tamperedBundle rekorBundle
+		tamperedBundle.Payload.Body = base64.StdEncoding.EncodeToString(tamperedBody)
+		tamperedBundle.Payload.IntegratedTime = integratedAt.Unix()
+		tamperedSetPayload, err := rekorSETPayload(tamperedBundle)
+		if err != nil {
+			t.Fatalf("encoding tampered bundle payload: %v", err)
+		}
+		tamperedHash := sha256.Sum256(tamperedSetPayload)
+		tamperedSet, err := ecdsa.SignASN1(rand.Reader, rekorKey, tamperedHash[:])
+		if err != nil {
+			t.Fatalf("signing tampered entry: %v", err)
+		}
+		tamperedBundle.SignedEntryTimestamp = base64.StdEncoding.EncodeToString(tamperedSet)
+		tamperedBundleJSON, err := json.Marshal(tamperedBundle)
+		if err != nil {
+			t.Fatalf("marshaling tampered bundle: %v", err)
+		}
+
+		tamperedImg, err := mutate.Append(empty.Image, mutate.Addendum{
+			Layer: static.NewLayer(payload, cosignSimpleSigningMediaType),
+			Annotations: map[string]string{
+				cosignSignatureAnnotation:   base64.StdEncoding.EncodeToString(sig),
+				cosignCertificateAnnotation: string(certPEM),
+				cosignBundleAnnotation:      string(tamperedBundleJSON),
+			},
+		})
+		if err != nil {
+			t.Fatalf("building tampered signature image: %v", err)
+		}
+		if err := remote.Write(sigRef, tamperedImg); err != nil {
+			t.Fatalf("pushing tampered signature: %v", err)
+		}
+
+		if _, err := c.VerifySignature(ctx, ref, VerifyOptions{
+			Identity:           identity,
+			Issuer:             issuer,
+			RekorPublicKeyPath: rekorPubPath,
+			FulcioRootPath:     caPath,
+		}); err == nil {
+			t.Error("VerifySignature succeeded for a Rekor entry logged against a different artifact")
+		}
+	})
+}